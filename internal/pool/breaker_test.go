@@ -0,0 +1,129 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newBreakerTestPool(dialer func(context.Context) (net.Conn, error)) *ConnPool {
+	return NewConnPool(&Options{
+		Dialer:                dialer,
+		PoolSize:              1,
+		PoolTimeout:           time.Second,
+		DialFailureThreshold:  2,
+		DialResetTimeout:      10 * time.Millisecond,
+		DialHalfOpenMaxProbes: 1,
+	})
+}
+
+func TestCircuitBreaker_OpensAfterThreshold(t *testing.T) {
+	wantErr := errors.New("dial failed")
+	p := newBreakerTestPool(func(context.Context) (net.Conn, error) {
+		return nil, wantErr
+	})
+	defer p.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := p.dialConn(ctx, true); !errors.Is(err, wantErr) {
+			t.Fatalf("dial %d: got err %v, want %v", i, err, wantErr)
+		}
+	}
+
+	if got := p.BreakerState(); got != BreakerOpen {
+		t.Fatalf("BreakerState() = %v, want BreakerOpen", got)
+	}
+	if got := p.Stats().CircuitOpens; got != 1 {
+		t.Fatalf("CircuitOpens = %d, want 1", got)
+	}
+
+	// While open, dialConn must fail immediately with the wrapped last
+	// error rather than spinning a goroutine or touching the network.
+	if _, err := p.dialConn(ctx, true); !errors.Is(err, wantErr) {
+		t.Fatalf("dial while open: got err %v, want wrapped %v", err, wantErr)
+	}
+}
+
+func TestCircuitBreaker_HalfOpenProbeRecovers(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	wantErr := errors.New("dial failed")
+
+	p := newBreakerTestPool(func(context.Context) (net.Conn, error) {
+		if failing.Load() {
+			return nil, wantErr
+		}
+		client, _ := net.Pipe()
+		return client, nil
+	})
+	defer p.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, _ = p.dialConn(ctx, true)
+	}
+	if got := p.BreakerState(); got != BreakerOpen {
+		t.Fatalf("BreakerState() = %v, want BreakerOpen", got)
+	}
+
+	time.Sleep(20 * time.Millisecond) // past DialResetTimeout
+
+	failing.Store(false)
+	cn, err := p.dialConn(ctx, true)
+	if err != nil {
+		t.Fatalf("half-open probe dial: %v", err)
+	}
+	defer cn.Close()
+
+	if got := p.BreakerState(); got != BreakerClosed {
+		t.Fatalf("BreakerState() after successful probe = %v, want BreakerClosed", got)
+	}
+}
+
+func TestCircuitBreaker_HalfOpenProbeCap(t *testing.T) {
+	wantErr := errors.New("dial failed")
+	release := make(chan struct{})
+	var calls int32
+
+	p := newBreakerTestPool(func(context.Context) (net.Conn, error) {
+		// The first two calls just cross DialFailureThreshold and open the
+		// breaker; only the first half-open probe (the 3rd call) blocks.
+		if atomic.AddInt32(&calls, 1) == 3 {
+			<-release
+		}
+		return nil, wantErr
+	})
+	defer p.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, _ = p.dialConn(ctx, true)
+	}
+
+	time.Sleep(20 * time.Millisecond) // past DialResetTimeout
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _ = p.dialConn(ctx, true) // claims the single half-open probe slot, blocks in Dialer
+	}()
+
+	// Give the goroutine above time to enter allowDial and claim the slot.
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err := p.dialConn(ctx, true); !errors.Is(err, wantErr) {
+		t.Fatalf("second concurrent half-open probe: got err %v, want rejection wrapping %v", err, wantErr)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Fatalf("Dialer invoked %d times, want exactly 3 (2 setup failures + 1 in-flight probe, no 2nd probe)", got)
+	}
+
+	close(release)
+	wg.Wait()
+}
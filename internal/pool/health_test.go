@@ -0,0 +1,84 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHealthChecker_EvictsConnFailingPing(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+
+	pingErr := errors.New("ping failed")
+	p := NewConnPool(&Options{
+		Dialer: func(context.Context) (net.Conn, error) {
+			return clientConn, nil
+		},
+		PoolSize:    1,
+		PoolTimeout: time.Second,
+		HealthCheckPing: func(net.Conn) error {
+			return pingErr
+		},
+	})
+	defer p.Close()
+
+	cn, err := p.dialConn(context.Background(), true)
+	if err != nil {
+		t.Fatalf("dialConn: %v", err)
+	}
+
+	p.connsMu.Lock()
+	p.conns.PushBack(cn)
+	p.idleConns.PushBack(cn)
+	p.idleConnsLen++
+	p.connsMu.Unlock()
+
+	p.checkIdleConnsHealth(context.Background())
+
+	if got := p.Stats().HealthCheckEvictions; got != 1 {
+		t.Fatalf("HealthCheckEvictions = %d, want 1", got)
+	}
+	if got := p.Stats().PingFailures; got != 1 {
+		t.Fatalf("PingFailures = %d, want 1", got)
+	}
+	if got := p.IdleLen(); got != 0 {
+		t.Fatalf("IdleLen() = %d, want 0 after eviction", got)
+	}
+}
+
+func TestHealthChecker_KeepsHealthyConn(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+
+	p := NewConnPool(&Options{
+		Dialer: func(context.Context) (net.Conn, error) {
+			return clientConn, nil
+		},
+		PoolSize:    1,
+		PoolTimeout: time.Second,
+	})
+	defer p.Close()
+
+	cn, err := p.dialConn(context.Background(), true)
+	if err != nil {
+		t.Fatalf("dialConn: %v", err)
+	}
+
+	p.connsMu.Lock()
+	p.conns.PushBack(cn)
+	p.idleConns.PushBack(cn)
+	p.idleConnsLen++
+	p.connsMu.Unlock()
+
+	p.checkIdleConnsHealth(context.Background())
+
+	if got := p.Stats().HealthCheckEvictions; got != 0 {
+		t.Fatalf("HealthCheckEvictions = %d, want 0", got)
+	}
+	if got := p.IdleLen(); got != 1 {
+		t.Fatalf("IdleLen() = %d, want 1, conn should not have been evicted", got)
+	}
+}
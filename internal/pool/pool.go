@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -19,6 +20,31 @@ var (
 
 	// ErrPoolTimeout timed out waiting to get a connection from the connection pool.
 	ErrPoolTimeout = errors.New("redis: connection pool timeout")
+
+	// ErrCircuitOpen is returned by Get/NewConn while the dial circuit
+	// breaker is open and no last dial error is available to wrap.
+	ErrCircuitOpen = errors.New("redis: dial circuit breaker open")
+)
+
+// BreakerState is the state of a ConnPool's dial circuit breaker.
+type BreakerState uint32
+
+const (
+	// BreakerClosed dials normally.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails dials immediately without touching the network.
+	BreakerOpen
+	// BreakerHalfOpen allows a bounded number of probe dials through to
+	// decide whether to close the breaker again.
+	BreakerHalfOpen
+)
+
+const (
+	// maxDialBackoff caps the exponential backoff between half-open probes.
+	maxDialBackoff = time.Minute
+	// dialBackoffJitter is the +/- fraction applied to the backoff so that
+	// many pools opened at the same time don't probe in lockstep.
+	dialBackoffJitter = 0.2
 )
 
 var timers = sync.Pool{
@@ -38,6 +64,12 @@ type Stats struct {
 	TotalConns uint32 // number of total connections in the pool
 	IdleConns  uint32 // number of idle connections in the pool
 	StaleConns uint32 // number of stale connections removed from the pool
+
+	DialErrors   uint32 // number of Dialer errors observed by the circuit breaker
+	CircuitOpens uint32 // number of times the dial circuit breaker has opened
+
+	HealthCheckEvictions uint32 // number of idle conns evicted by the background health checker
+	PingFailures         uint32 // number of HealthCheckPing failures observed by the background health checker
 }
 
 type Pooler interface {
@@ -65,6 +97,34 @@ type Options struct {
 	MaxIdleConns    int
 	ConnMaxIdleTime time.Duration
 	ConnMaxLifetime time.Duration
+
+	// DialFailureThreshold is the number of consecutive Dialer failures
+	// after which the pool opens its circuit breaker and stops dialing
+	// until DialResetTimeout has elapsed. Defaults to PoolSize.
+	DialFailureThreshold int
+	// DialResetTimeout is the base backoff before an open breaker allows
+	// a half-open probe dial. It grows exponentially (capped) on repeated
+	// failures and is jittered by +/-20% to avoid a thundering herd of
+	// probes. Defaults to 1 second.
+	DialResetTimeout time.Duration
+	// DialHalfOpenMaxProbes bounds how many dials may be in flight at once
+	// while the breaker is half-open. Defaults to 1.
+	DialHalfOpenMaxProbes int
+
+	// HealthCheckInterval is the period of the background worker that
+	// reaps stale idle connections (see ConnMaxLifetime/ConnMaxIdleTime)
+	// and proactively health-checks the rest. A connection whose UsedAt is
+	// within the last HealthCheckInterval is trusted by Get without repeating
+	// the connCheck syscall. Zero disables both the background worker and the
+	// Get-time skip, falling back to a connCheck on every Get.
+	HealthCheckInterval time.Duration
+
+	// HealthCheckPing, if set, is run against an idle connection's raw
+	// net.Conn by the background health checker in addition to connCheck,
+	// e.g. to round-trip a PING at the protocol level. The pool package
+	// has no notion of the wire protocol, so callers that want this wire
+	// it in; a nil func skips the ping.
+	HealthCheckPing func(net.Conn) error
 }
 
 type lastDialErrorWrap struct {
@@ -74,9 +134,13 @@ type lastDialErrorWrap struct {
 type ConnPool struct {
 	cfg *Options
 
-	dialErrorsNum uint32 // atomic
 	lastDialError atomic.Value
 
+	breakerState   uint32 // atomic, BreakerState
+	openedAt       int64  // atomic, UnixNano of the last breaker open
+	consecFailures uint32 // atomic
+	halfOpenProbes int32  // atomic, in-flight half-open probe dials
+
 	queue chan struct{}
 
 	connsMu   sync.Mutex
@@ -106,6 +170,10 @@ func NewConnPool(opt *Options) *ConnPool {
 	p.checkMinIdleConns()
 	p.connsMu.Unlock()
 
+	if opt.HealthCheckInterval > 0 {
+		go p.reaper(opt.HealthCheckInterval)
+	}
+
 	return p
 }
 
@@ -185,41 +253,139 @@ func (p *ConnPool) dialConn(ctx context.Context, pooled bool) (*Conn, error) {
 		return nil, ErrClosed
 	}
 
-	if atomic.LoadUint32(&p.dialErrorsNum) >= uint32(p.cfg.PoolSize) {
-		return nil, p.getLastDialError()
+	probe, err := p.allowDial()
+	if err != nil {
+		return nil, err
 	}
 
 	netConn, err := p.cfg.Dialer(ctx)
 	if err != nil {
-		p.setLastDialError(err)
-		if atomic.AddUint32(&p.dialErrorsNum, 1) == uint32(p.cfg.PoolSize) {
-			go p.tryDial()
-		}
+		p.onDialError(err, probe)
 		return nil, err
 	}
+	p.onDialSuccess(probe)
 
 	cn := NewConn(netConn)
 	cn.pooled = pooled
 	return cn, nil
 }
 
-func (p *ConnPool) tryDial() {
-	for {
-		if p.closed() {
-			return
+// BreakerState reports the current state of the dial circuit breaker.
+func (p *ConnPool) BreakerState() BreakerState {
+	return BreakerState(atomic.LoadUint32(&p.breakerState))
+}
+
+func (p *ConnPool) failureThreshold() uint32 {
+	if p.cfg.DialFailureThreshold > 0 {
+		return uint32(p.cfg.DialFailureThreshold)
+	}
+	return uint32(p.cfg.PoolSize)
+}
+
+func (p *ConnPool) resetTimeout() time.Duration {
+	if p.cfg.DialResetTimeout > 0 {
+		return p.cfg.DialResetTimeout
+	}
+	return time.Second
+}
+
+func (p *ConnPool) halfOpenMaxProbes() int32 {
+	if p.cfg.DialHalfOpenMaxProbes > 0 {
+		return int32(p.cfg.DialHalfOpenMaxProbes)
+	}
+	return 1
+}
+
+// backoff returns the jittered exponential backoff to wait out before
+// the next half-open probe, given the current run of consecutive
+// failures.
+func (p *ConnPool) backoff(failures uint32) time.Duration {
+	threshold := p.failureThreshold()
+	shift := failures - threshold
+	if shift > 5 {
+		shift = 5
+	}
+
+	d := p.resetTimeout() << shift
+	if d <= 0 || d > maxDialBackoff {
+		d = maxDialBackoff
+	}
+
+	jitter := time.Duration((rand.Float64()*2 - 1) * dialBackoffJitter * float64(d))
+	return d + jitter
+}
+
+// allowDial decides whether a dial attempt may proceed under the
+// current breaker state, flipping an expired open breaker to half-open.
+// The returned probe flag tells the caller whether this attempt holds a
+// half-open probe slot that must be released via onDialError/onDialSuccess.
+func (p *ConnPool) allowDial() (probe bool, err error) {
+	state := BreakerState(atomic.LoadUint32(&p.breakerState))
+
+	if state == BreakerOpen {
+		failures := atomic.LoadUint32(&p.consecFailures)
+		openedAt := time.Unix(0, atomic.LoadInt64(&p.openedAt))
+		if time.Since(openedAt) < p.backoff(failures) {
+			return false, p.breakerOpenError()
 		}
+		atomic.CompareAndSwapUint32(&p.breakerState, uint32(BreakerOpen), uint32(BreakerHalfOpen))
+		state = BreakerHalfOpen
+	}
 
-		conn, err := p.cfg.Dialer(context.Background())
-		if err != nil {
-			p.setLastDialError(err)
-			time.Sleep(time.Second)
-			continue
+	if state == BreakerHalfOpen {
+		if atomic.AddInt32(&p.halfOpenProbes, 1) > p.halfOpenMaxProbes() {
+			atomic.AddInt32(&p.halfOpenProbes, -1)
+			return false, p.breakerOpenError()
 		}
+		return true, nil
+	}
 
-		atomic.StoreUint32(&p.dialErrorsNum, 0)
-		_ = conn.Close()
-		return
+	return false, nil
+}
+
+func (p *ConnPool) onDialError(err error, probe bool) {
+	p.setLastDialError(err)
+	atomic.AddUint32(&p.stats.DialErrors, 1)
+
+	if probe {
+		atomic.AddInt32(&p.halfOpenProbes, -1)
+	}
+
+	failures := atomic.AddUint32(&p.consecFailures, 1)
+
+	switch BreakerState(atomic.LoadUint32(&p.breakerState)) {
+	case BreakerHalfOpen:
+		p.openBreaker()
+	case BreakerClosed:
+		if failures >= p.failureThreshold() {
+			p.openBreaker()
+		}
+	}
+}
+
+func (p *ConnPool) onDialSuccess(probe bool) {
+	if probe {
+		atomic.AddInt32(&p.halfOpenProbes, -1)
 	}
+	atomic.StoreUint32(&p.consecFailures, 0)
+	atomic.StoreUint32(&p.breakerState, uint32(BreakerClosed))
+}
+
+// openBreaker transitions into the open state and resets the backoff
+// clock. It is safe to call repeatedly (e.g. from concurrent half-open
+// probe failures); only the first caller counts a CircuitOpens event.
+func (p *ConnPool) openBreaker() {
+	atomic.StoreInt64(&p.openedAt, time.Now().UnixNano())
+	if atomic.SwapUint32(&p.breakerState, uint32(BreakerOpen)) != uint32(BreakerOpen) {
+		atomic.AddUint32(&p.stats.CircuitOpens, 1)
+	}
+}
+
+func (p *ConnPool) breakerOpenError() error {
+	if err := p.getLastDialError(); err != nil {
+		return fmt.Errorf("redis: dial circuit breaker open: %w", err)
+	}
+	return ErrCircuitOpen
 }
 
 func (p *ConnPool) setLastDialError(err error) {
@@ -265,7 +431,17 @@ func (p *ConnPool) Get(ctx context.Context) (*Conn, error) {
 			continue
 		}
 
-		if !p.isHealthyConn(cn) {
+		// A conn used within the last HealthCheckInterval is trusted as-is,
+		// skipping the connCheck syscall on this hot path. ConnMaxLifetime is keyed off
+		// createdAt rather than UsedAt, so a conn kept continuously busy
+		// still has to clear it here or it would never be rotated out.
+		if p.cfg.HealthCheckInterval > 0 && time.Since(cn.UsedAt()) < p.cfg.HealthCheckInterval &&
+			(p.cfg.ConnMaxLifetime <= 0 || time.Since(cn.createdAt) < p.cfg.ConnMaxLifetime) {
+			atomic.AddUint32(&p.stats.Hits, 1)
+			return cn, nil
+		}
+
+		if !p.isHealthyConn(ctx, cn) {
 			p.AsyncCloseConn(cn)
 			continue
 		}
@@ -370,7 +546,14 @@ func (p *ConnPool) Put(ctx context.Context, cn *Conn) {
 
 	p.connsMu.Lock()
 
-	if p.cfg.MaxIdleConns == 0 || p.idleConnsLen < p.cfg.MaxIdleConns {
+	// A conn can still be outstanding from a pool that was Close()'d in
+	// the meantime (e.g. RingPool draining a removed shard out from under
+	// a caller's checked-out conn). p.idleConns/p.conns are nil at that
+	// point, so just hand the conn off to be closed instead of touching
+	// them.
+	if p.closed() {
+		shouldCloseConn = true
+	} else if p.cfg.MaxIdleConns == 0 || p.idleConnsLen < p.cfg.MaxIdleConns {
 		p.idleConns.PushBack(cn)
 		p.idleConnsLen++
 	} else {
@@ -425,6 +608,13 @@ func (p *ConnPool) removeConnWithLock(cn *Conn) {
 }
 
 func (p *ConnPool) removeConn(cn *Conn) {
+	// Close() nils out p.conns; a conn that outlived its pool's Close()
+	// (Remove/CloseConn racing a caller that still holds it) has nothing
+	// left to remove itself from.
+	if p.conns == nil {
+		return
+	}
+
 	var c *Conn
 	for e := p.conns.Front(); e != nil; e = e.Next() {
 		c = e.Value.(*Conn)
@@ -468,6 +658,12 @@ func (p *ConnPool) Stats() *Stats {
 		TotalConns: uint32(p.Len()),
 		IdleConns:  uint32(p.IdleLen()),
 		StaleConns: atomic.LoadUint32(&p.stats.StaleConns),
+
+		DialErrors:   atomic.LoadUint32(&p.stats.DialErrors),
+		CircuitOpens: atomic.LoadUint32(&p.stats.CircuitOpens),
+
+		HealthCheckEvictions: atomic.LoadUint32(&p.stats.HealthCheckEvictions),
+		PingFailures:         atomic.LoadUint32(&p.stats.PingFailures),
 	}
 }
 
@@ -516,20 +712,20 @@ func (p *ConnPool) Close() error {
 	return firstErr
 }
 
-func (p *ConnPool) isHealthyConn(cn *Conn) bool {
+func (p *ConnPool) isHealthyConn(ctx context.Context, cn *Conn) bool {
 	now := time.Now()
 
 	if p.cfg.ConnMaxLifetime > 0 && now.Sub(cn.createdAt) >= p.cfg.ConnMaxLifetime {
-		fmt.Printf("redis check conn max life time now:%s use at:%s, %d\n", now.Format("2006-01-02 15:04:05"), cn.createdAt.Format("2006-01-02 15:04:05"), p.cfg.ConnMaxLifetime.Milliseconds())
+		internal.Logger.Printf(ctx, "redis: conn exceeded max lifetime now:%s created_at:%s max_lifetime_ms:%d", now.Format("2006-01-02 15:04:05"), cn.createdAt.Format("2006-01-02 15:04:05"), p.cfg.ConnMaxLifetime.Milliseconds())
 		return false
 	}
 	if p.cfg.ConnMaxIdleTime > 0 && now.Sub(cn.UsedAt()) >= p.cfg.ConnMaxIdleTime {
-		fmt.Printf("redis check conn max idle time now:%s create at:%s use at:%s, %d\n", now.Format("2006-01-02 15:04:05"), cn.createdAt.Format("2006-01-02 15:04:05"), cn.UsedAt().Format("2006-01-02 15:04:05"), p.cfg.ConnMaxIdleTime.Milliseconds())
+		internal.Logger.Printf(ctx, "redis: conn exceeded max idle time now:%s created_at:%s used_at:%s max_idle_time_ms:%d", now.Format("2006-01-02 15:04:05"), cn.createdAt.Format("2006-01-02 15:04:05"), cn.UsedAt().Format("2006-01-02 15:04:05"), p.cfg.ConnMaxIdleTime.Milliseconds())
 		return false
 	}
 
 	if err := connCheck(cn.netConn); err != nil {
-		fmt.Printf("redis check err:%s", err.Error())
+		internal.Logger.Printf(ctx, "redis: conn check failed: %s", err)
 		return false
 	}
 
@@ -537,27 +733,32 @@ func (p *ConnPool) isHealthyConn(cn *Conn) bool {
 	return true
 }
 
-func (p *ConnPool) isStaleConn(cn *Conn) bool {
+func (p *ConnPool) isStaleConn(ctx context.Context, cn *Conn) bool {
 	now := time.Now()
 
 	if p.cfg.ConnMaxLifetime > 0 && now.Sub(cn.createdAt) >= p.cfg.ConnMaxLifetime {
-		fmt.Printf("redis stale check conn max life time now:%s use at:%s, %d\n", now.Format("2006-01-02 15:04:05"), cn.createdAt.Format("2006-01-02 15:04:05"), p.cfg.ConnMaxLifetime.Milliseconds())
+		internal.Logger.Printf(ctx, "redis: stale check: conn exceeded max lifetime now:%s created_at:%s max_lifetime_ms:%d", now.Format("2006-01-02 15:04:05"), cn.createdAt.Format("2006-01-02 15:04:05"), p.cfg.ConnMaxLifetime.Milliseconds())
 		return true
 	}
 	if p.cfg.ConnMaxIdleTime > 0 && now.Sub(cn.UsedAt()) >= p.cfg.ConnMaxIdleTime {
-		fmt.Printf("redis stale  check conn max idle time now:%s created_at:%s use at:%s, %d\n", now.Format("2006-01-02 15:04:05"), cn.createdAt.Format("2006-01-02 15:04:05"), cn.UsedAt().Format("2006-01-02 15:04:05"), p.cfg.ConnMaxIdleTime.Milliseconds())
+		internal.Logger.Printf(ctx, "redis: stale check: conn exceeded max idle time now:%s created_at:%s used_at:%s max_idle_time_ms:%d", now.Format("2006-01-02 15:04:05"), cn.createdAt.Format("2006-01-02 15:04:05"), cn.UsedAt().Format("2006-01-02 15:04:05"), p.cfg.ConnMaxIdleTime.Milliseconds())
 		return true
 	}
 
 	if err := connCheck(cn.netConn); err != nil {
-		fmt.Printf("redis stale check  err:%s", err.Error())
+		internal.Logger.Printf(ctx, "redis: stale check: conn check failed: %s", err)
 		return true
 	}
 
 	return false
 }
 
+// reaper is the single ticker-driven background worker for a ConnPool. It
+// reaps stale idle connections and runs the proactive health check on the
+// remaining idle ones, both under connsMu, so the two jobs never contend
+// with each other for the lock.
 func (p *ConnPool) reaper(frequency time.Duration) {
+	ctx := context.Background()
 	ticker := time.NewTicker(frequency)
 	defer ticker.Stop()
 	for {
@@ -569,18 +770,19 @@ func (p *ConnPool) reaper(frequency time.Duration) {
 			if p.closed() {
 				return
 			}
-			_, _ = p.ReapStaleConns()
+			_, _ = p.ReapStaleConns(ctx)
+			p.checkIdleConnsHealth(ctx)
 		}
 	}
 }
 
-func (p *ConnPool) ReapStaleConns() (int, error) {
+func (p *ConnPool) ReapStaleConns(ctx context.Context) (int, error) {
 	var n int
 	for {
 		p.getTurn()
 
 		p.connsMu.Lock()
-		cn := p.reapStaleConn()
+		cn := p.reapStaleConn(ctx)
 		p.connsMu.Unlock()
 
 		p.freeTurn()
@@ -597,14 +799,14 @@ func (p *ConnPool) ReapStaleConns() (int, error) {
 	return n, nil
 }
 
-func (p *ConnPool) reapStaleConn() *Conn {
-	if p.idleConns.Len() == 0 {
+func (p *ConnPool) reapStaleConn(ctx context.Context) *Conn {
+	if p.idleConns == nil || p.idleConns.Len() == 0 {
 		return nil
 	}
 
 	el := p.idleConns.Front()
 	cn := el.Value.(*Conn)
-	if !p.isStaleConn(cn) {
+	if !p.isStaleConn(ctx, cn) {
 		return nil
 	}
 	p.idleConns.Remove(el)
@@ -612,3 +814,62 @@ func (p *ConnPool) reapStaleConn() *Conn {
 	p.removeConn(cn)
 	return cn
 }
+
+// healthCheckBatchSize bounds how many idle conns checkIdleConnsHealth
+// walks per tick, so a pool with a large MaxIdleConns can't turn a single
+// tick into a long connsMu hold.
+const healthCheckBatchSize = 50
+
+// checkIdleConnsHealth walks up to healthCheckBatchSize idle connections
+// and evicts any that fail connCheck or the optional HealthCheckPing probe.
+// It does not update UsedAt of the conns it keeps.
+func (p *ConnPool) checkIdleConnsHealth(ctx context.Context) {
+	p.connsMu.Lock()
+	if p.idleConns == nil {
+		p.connsMu.Unlock()
+		return
+	}
+	var unhealthy []*Conn
+	checked := 0
+	var next *list.Element
+	for e := p.idleConns.Front(); e != nil && checked < healthCheckBatchSize; e = next {
+		next = e.Next()
+		cn := e.Value.(*Conn)
+		checked++
+
+		healthy := true
+		if err := connCheck(cn.netConn); err != nil {
+			internal.Logger.Printf(ctx, "redis: health check conn check failed: %s", err)
+			healthy = false
+		} else if !p.pingConn(ctx, cn) {
+			healthy = false
+		}
+
+		if !healthy {
+			// Pop it out of idleConns now, under the lock we're already
+			// holding; AsyncCloseConn (called below, without the lock)
+			// only knows how to remove a conn from the full conns list.
+			p.idleConns.Remove(e)
+			p.idleConnsLen--
+			unhealthy = append(unhealthy, cn)
+		}
+	}
+	p.connsMu.Unlock()
+
+	for _, cn := range unhealthy {
+		atomic.AddUint32(&p.stats.HealthCheckEvictions, 1)
+		p.AsyncCloseConn(cn)
+	}
+}
+
+func (p *ConnPool) pingConn(ctx context.Context, cn *Conn) bool {
+	if p.cfg.HealthCheckPing == nil {
+		return true
+	}
+	if err := p.cfg.HealthCheckPing(cn.netConn); err != nil {
+		atomic.AddUint32(&p.stats.PingFailures, 1)
+		internal.Logger.Printf(ctx, "redis: health check ping failed: %s", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,277 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/dgryski/go-rendezvous"
+)
+
+// ErrRingNoMasters is returned by RingPool when it has no live master
+// sub-pool to route a request to.
+var ErrRingNoMasters = errors.New("redis: ring pool has no live masters")
+
+type ringRoutingKeyCtxKey struct{}
+
+// WithRingRoutingKey attaches a routing key to ctx so that a later
+// RingPool.Get/NewConn call can pick the master sub-pool that owns it.
+// Callers typically derive the key from the command's Redis key (or hash
+// tag). A ctx with no routing key always resolves to the same master,
+// which is fine for commands that are not key-scoped.
+func WithRingRoutingKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, ringRoutingKeyCtxKey{}, key)
+}
+
+func ringRoutingKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(ringRoutingKeyCtxKey{}).(string)
+	return key
+}
+
+// RingMaster describes one master that should be behind the ring, e.g.
+// as reported by a sentinel watcher.
+type RingMaster struct {
+	Name string
+	// Addr identifies which host:port Opt.Dialer actually connects to.
+	// SetMasters uses it to tell a Sentinel failover (same Name, new
+	// Addr) apart from a no-op update, since Opt.Dialer is a closure and
+	// can't be compared directly.
+	Addr string
+	Opt  *Options
+}
+
+// RingOptions configures a RingPool.
+type RingOptions struct {
+	// NewSubPool builds the ConnPool for a newly discovered master.
+	// Defaults to NewConnPool.
+	NewSubPool func(opt *Options) *ConnPool
+}
+
+type ringShard struct {
+	name string
+	addr string
+	pool *ConnPool
+}
+
+// RingPool is a Pooler that fronts one ConnPool per Redis master and
+// routes Get/NewConn to the master owning the caller's routing key (see
+// WithRingRoutingKey), selected by rendezvous (HRW) hashing over the
+// current live master set. Unlike modulo hashing, adding or removing a
+// master only reshuffles the ~1/N keys that hashed to it, so reconciling
+// the ring after a Sentinel failover moves a minimal slice of traffic.
+//
+// Callers normally drive SetMasters from a sentinel watcher reacting to
+// +switch-master / +odown events.
+type RingPool struct {
+	opt *RingOptions
+
+	mu     sync.RWMutex
+	shards map[string]*ringShard
+	hash   *rendezvous.Rendezvous
+
+	ownerMu sync.Mutex
+	owner   map[*Conn]*ConnPool
+}
+
+var _ Pooler = (*RingPool)(nil)
+
+func NewRingPool(opt *RingOptions) *RingPool {
+	if opt.NewSubPool == nil {
+		opt.NewSubPool = NewConnPool
+	}
+	return &RingPool{
+		opt:    opt,
+		shards: make(map[string]*ringShard),
+		hash:   rendezvous.New(nil, xxhash.Sum64String),
+		owner:  make(map[*Conn]*ConnPool),
+	}
+}
+
+// SetMasters atomically reconciles the ring with the given master set:
+// masters no longer present are closed, masters not seen before get a
+// fresh sub-pool, and a master whose Addr is unchanged is left untouched
+// so its connections survive the reconfiguration. A master present under
+// the same Name but a different Addr — a Sentinel +switch-master, where
+// the logical name is stable but the backing host:port moves — is
+// rebuilt from scratch, since otherwise its old sub-pool would keep
+// dialing the demoted instance forever.
+//
+// Closing a removed/rebuilt sub-pool does not wait for its outstanding
+// connections to be returned; a caller that still holds one finds Put/
+// Remove/CloseConn safe to call on it regardless.
+func (r *RingPool) SetMasters(masters []RingMaster) {
+	wanted := make(map[string]*RingMaster, len(masters))
+	for i := range masters {
+		wanted[masters[i].Name] = &masters[i]
+	}
+
+	r.mu.Lock()
+
+	var removed []*ringShard
+	for name, shard := range r.shards {
+		m, ok := wanted[name]
+		if !ok || m.Addr != shard.addr {
+			removed = append(removed, shard)
+			delete(r.shards, name)
+		}
+	}
+
+	for name, m := range wanted {
+		if _, ok := r.shards[name]; ok {
+			continue
+		}
+		r.shards[name] = &ringShard{name: name, addr: m.Addr, pool: r.opt.NewSubPool(m.Opt)}
+	}
+
+	names := make([]string, 0, len(r.shards))
+	for name := range r.shards {
+		names = append(names, name)
+	}
+	r.hash = rendezvous.New(names, xxhash.Sum64String)
+
+	r.mu.Unlock()
+
+	for _, shard := range removed {
+		_ = shard.pool.Close()
+	}
+}
+
+func (r *RingPool) pick(ctx context.Context) (*ringShard, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.shards) == 0 {
+		return nil, ErrRingNoMasters
+	}
+
+	name := r.hash.Lookup(ringRoutingKeyFromContext(ctx))
+	shard, ok := r.shards[name]
+	if !ok {
+		return nil, ErrRingNoMasters
+	}
+	return shard, nil
+}
+
+func (r *RingPool) NewConn(ctx context.Context) (*Conn, error) {
+	shard, err := r.pick(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cn, err := shard.pool.NewConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.setOwner(cn, shard.pool)
+	return cn, nil
+}
+
+func (r *RingPool) Get(ctx context.Context) (*Conn, error) {
+	shard, err := r.pick(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cn, err := shard.pool.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.setOwner(cn, shard.pool)
+	return cn, nil
+}
+
+func (r *RingPool) Put(ctx context.Context, cn *Conn) {
+	if owner := r.takeOwner(cn); owner != nil {
+		owner.Put(ctx, cn)
+	}
+}
+
+func (r *RingPool) Remove(ctx context.Context, cn *Conn, reason error) {
+	if owner := r.takeOwner(cn); owner != nil {
+		owner.Remove(ctx, cn, reason)
+	}
+}
+
+func (r *RingPool) CloseConn(cn *Conn) error {
+	if owner := r.takeOwner(cn); owner != nil {
+		return owner.CloseConn(cn)
+	}
+	return nil
+}
+
+func (r *RingPool) setOwner(cn *Conn, owner *ConnPool) {
+	r.ownerMu.Lock()
+	r.owner[cn] = owner
+	r.ownerMu.Unlock()
+}
+
+func (r *RingPool) takeOwner(cn *Conn) *ConnPool {
+	r.ownerMu.Lock()
+	owner := r.owner[cn]
+	delete(r.owner, cn)
+	r.ownerMu.Unlock()
+	return owner
+}
+
+func (r *RingPool) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var n int
+	for _, shard := range r.shards {
+		n += shard.pool.Len()
+	}
+	return n
+}
+
+func (r *RingPool) IdleLen() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var n int
+	for _, shard := range r.shards {
+		n += shard.pool.IdleLen()
+	}
+	return n
+}
+
+// Stats aggregates Stats across every live master sub-pool.
+func (r *RingPool) Stats() *Stats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := &Stats{}
+	for _, shard := range r.shards {
+		s := shard.pool.Stats()
+		stats.Hits += s.Hits
+		stats.Misses += s.Misses
+		stats.Timeouts += s.Timeouts
+		stats.TotalConns += s.TotalConns
+		stats.IdleConns += s.IdleConns
+		stats.StaleConns += s.StaleConns
+		stats.DialErrors += s.DialErrors
+		stats.CircuitOpens += s.CircuitOpens
+		stats.HealthCheckEvictions += s.HealthCheckEvictions
+		stats.PingFailures += s.PingFailures
+	}
+	return stats
+}
+
+func (r *RingPool) Close() error {
+	r.mu.Lock()
+	shards := r.shards
+	r.shards = make(map[string]*ringShard)
+	r.hash = rendezvous.New(nil, xxhash.Sum64String)
+	r.mu.Unlock()
+
+	var firstErr error
+	for _, shard := range shards {
+		if err := shard.pool.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
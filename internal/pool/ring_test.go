@@ -0,0 +1,150 @@
+package pool
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func newFakeSubPool(opt *Options) *ConnPool {
+	return NewConnPool(&Options{
+		Dialer: func(context.Context) (net.Conn, error) {
+			client, _ := net.Pipe()
+			return client, nil
+		},
+		PoolSize:    1,
+		PoolTimeout: time.Second,
+	})
+}
+
+func TestRingPool_SetMasters_StableUnderUnrelatedRemoval(t *testing.T) {
+	r := NewRingPool(&RingOptions{NewSubPool: newFakeSubPool})
+	defer r.Close()
+
+	r.SetMasters([]RingMaster{{Name: "m1"}, {Name: "m2"}, {Name: "m3"}})
+
+	ctx := WithRingRoutingKey(context.Background(), "some-routing-key")
+	shard, err := r.pick(ctx)
+	if err != nil {
+		t.Fatalf("pick: %v", err)
+	}
+	owningMaster := shard.name
+
+	// Pick an unrelated master to drop - one that does not own our key.
+	var dropped string
+	for name := range r.shards {
+		if name != owningMaster {
+			dropped = name
+			break
+		}
+	}
+
+	remaining := make([]RingMaster, 0, 2)
+	for name := range r.shards {
+		if name != dropped {
+			remaining = append(remaining, RingMaster{Name: name})
+		}
+	}
+	r.SetMasters(remaining)
+
+	shardAfter, err := r.pick(ctx)
+	if err != nil {
+		t.Fatalf("pick after rehash: %v", err)
+	}
+	if shardAfter.name != owningMaster {
+		t.Fatalf("key routed to %q before removing unrelated master %q, but moved to %q after (rendezvous hashing should leave it in place)",
+			owningMaster, dropped, shardAfter.name)
+	}
+}
+
+func TestRingPool_SetMasters_AddAndRemove(t *testing.T) {
+	r := NewRingPool(&RingOptions{NewSubPool: newFakeSubPool})
+	defer r.Close()
+
+	r.SetMasters([]RingMaster{{Name: "m1"}})
+	if got := len(r.shards); got != 1 {
+		t.Fatalf("len(shards) = %d, want 1", got)
+	}
+
+	r.SetMasters([]RingMaster{{Name: "m1"}, {Name: "m2"}})
+	if got := len(r.shards); got != 2 {
+		t.Fatalf("len(shards) = %d, want 2 after adding m2", got)
+	}
+
+	r.SetMasters([]RingMaster{{Name: "m2"}})
+	if got := len(r.shards); got != 1 {
+		t.Fatalf("len(shards) = %d, want 1 after removing m1", got)
+	}
+	if _, ok := r.shards["m2"]; !ok {
+		t.Fatalf("shards = %v, want m2 to remain", r.shards)
+	}
+
+	if _, err := r.pick(context.Background()); err != nil {
+		t.Fatalf("pick: %v", err)
+	}
+}
+
+// TestRingPool_SetMasters_RebuildsShardOnAddrChange is a regression test for
+// a Sentinel +switch-master: the master keeps its Name but SetMasters is
+// called with a new Addr, which must close the old sub-pool (still dialing
+// the demoted instance) and build a fresh one rather than treating the
+// known Name as unchanged.
+func TestRingPool_SetMasters_RebuildsShardOnAddrChange(t *testing.T) {
+	r := NewRingPool(&RingOptions{NewSubPool: newFakeSubPool})
+	defer r.Close()
+
+	r.SetMasters([]RingMaster{{Name: "m1", Addr: "10.0.0.1:6379"}})
+	before := r.shards["m1"].pool
+
+	r.SetMasters([]RingMaster{{Name: "m1", Addr: "10.0.0.2:6379"}})
+	after := r.shards["m1"].pool
+
+	if after == before {
+		t.Fatalf("SetMasters kept the same sub-pool across an Addr change, want a rebuilt shard")
+	}
+	if !before.closed() {
+		t.Fatalf("old sub-pool not closed after its master's Addr changed")
+	}
+	if got := r.shards["m1"].addr; got != "10.0.0.2:6379" {
+		t.Fatalf("shard addr = %q, want 10.0.0.2:6379", got)
+	}
+}
+
+func TestRingPool_SetMasters_NoRebuildWhenAddrUnchanged(t *testing.T) {
+	r := NewRingPool(&RingOptions{NewSubPool: newFakeSubPool})
+	defer r.Close()
+
+	r.SetMasters([]RingMaster{{Name: "m1", Addr: "10.0.0.1:6379"}})
+	before := r.shards["m1"].pool
+
+	r.SetMasters([]RingMaster{{Name: "m1", Addr: "10.0.0.1:6379"}})
+	after := r.shards["m1"].pool
+
+	if after != before {
+		t.Fatalf("SetMasters rebuilt the shard though its Addr did not change")
+	}
+}
+
+// TestRingPool_PutAfterShardRemovedDoesNotPanic is a regression test: a conn
+// checked out from a shard that SetMasters then drains and Close()s must
+// still be returnable via Put without panicking (see ConnPool.Put/removeConn
+// nil-list guards).
+func TestRingPool_PutAfterShardRemovedDoesNotPanic(t *testing.T) {
+	r := NewRingPool(&RingOptions{NewSubPool: newFakeSubPool})
+	defer r.Close()
+
+	r.SetMasters([]RingMaster{{Name: "only"}})
+
+	ctx := context.Background()
+	cn, err := r.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	// Removing "only" drains and Closes its ConnPool while cn is still
+	// checked out by this test.
+	r.SetMasters(nil)
+
+	r.Put(ctx, cn)
+}
@@ -0,0 +1,66 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/jeevic/go-redis/v9/internal/pool"
+	"github.com/jeevic/go-redis/v9/sentinel"
+)
+
+// RingFailoverOptions configures a RingClient: a set of Sentinel-monitored
+// masters fronted by a single pool.RingPool, routed by rendezvous hashing
+// over MasterNames (see sentinel.Watcher, pool.RingPool).
+type RingFailoverOptions struct {
+	// MasterNames are the Sentinel master names to watch; each becomes one
+	// ring shard.
+	MasterNames []string
+	// SentinelAddrs are the addresses of the Sentinel processes watching
+	// MasterNames.
+	SentinelAddrs []string
+	// SentinelPassword authenticates against SentinelAddrs, if set.
+	SentinelPassword string
+
+	// PoolOptions is the per-master pool.Options template; Dialer is
+	// overridden per master to dial its current address.
+	PoolOptions pool.Options
+}
+
+// RingClient fronts a Sentinel-discovered set of masters behind a single
+// pool.RingPool, kept in sync with the Sentinel-reported master set for
+// the lifetime of the client. Use NewRingFailoverClient to build one.
+type RingClient struct {
+	pool    *pool.RingPool
+	watcher *sentinel.Watcher
+}
+
+// NewRingFailoverClient discovers opt.MasterNames via opt.SentinelAddrs and
+// returns a RingClient that keeps routing in sync with Sentinel's view of
+// those masters until Close is called or ctx is done.
+func NewRingFailoverClient(ctx context.Context, opt *RingFailoverOptions) (*RingClient, error) {
+	cfg := sentinel.Config{
+		MasterNames:      opt.MasterNames,
+		SentinelAddrs:    opt.SentinelAddrs,
+		SentinelPassword: opt.SentinelPassword,
+		Options:          &opt.PoolOptions,
+	}
+
+	ringPool, watcher, err := sentinel.NewRingPool(ctx, cfg, &pool.RingOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RingClient{pool: ringPool, watcher: watcher}, nil
+}
+
+// Pool returns the underlying Pooler, for commands to route Get/Put/Remove
+// calls through (see pool.WithRingRoutingKey).
+func (c *RingClient) Pool() pool.Pooler {
+	return c.pool
+}
+
+// Close stops the background Sentinel watcher and closes every master
+// sub-pool.
+func (c *RingClient) Close() error {
+	_ = c.watcher.Close()
+	return c.pool.Close()
+}
@@ -0,0 +1,326 @@
+// Package sentinel discovers Redis masters behind one or more Sentinel
+// processes and pushes updates to a subscriber (typically a
+// pool.RingPool) whenever the master set changes, so that callers never
+// have to poll SENTINEL masters themselves.
+package sentinel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jeevic/go-redis/v9/internal"
+	"github.com/jeevic/go-redis/v9/internal/pool"
+	"github.com/jeevic/go-redis/v9/internal/proto"
+)
+
+// MasterInfo is one master entry as reported by SENTINEL masters.
+type MasterInfo struct {
+	Name string
+	Addr string // host:port
+}
+
+// Config configures a Watcher.
+type Config struct {
+	MasterNames      []string
+	SentinelAddrs    []string
+	SentinelPassword string
+
+	// DialTimeout bounds connecting to a sentinel. Defaults to 5 seconds.
+	// No deadline is set on the commands sent or replies read afterwards.
+	DialTimeout time.Duration
+
+	// Options is the per-master pool.Options template; Dialer is
+	// overridden per master to dial its current address.
+	Options *pool.Options
+}
+
+// Watcher keeps a live view of Config.MasterNames by querying Sentinel
+// and re-querying on every +switch-master / +odown notification. It is
+// the sentinel.v9-flavoured counterpart of pool.RingPool.SetMasters.
+type Watcher struct {
+	cfg      Config
+	onUpdate func([]pool.RingMaster)
+
+	mu            sync.Mutex
+	sentinelAddrs []string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewWatcher creates a Watcher that calls onUpdate with the current
+// master set every time membership changes. Start must be called to
+// begin discovery.
+func NewWatcher(cfg Config, onUpdate func([]pool.RingMaster)) *Watcher {
+	if cfg.DialTimeout == 0 {
+		cfg.DialTimeout = 5 * time.Second
+	}
+
+	addrs := make([]string, len(cfg.SentinelAddrs))
+	copy(addrs, cfg.SentinelAddrs)
+
+	return &Watcher{
+		cfg:           cfg,
+		onUpdate:      onUpdate,
+		sentinelAddrs: addrs,
+	}
+}
+
+// Start performs an initial discovery and then watches for changes in
+// the background until ctx is done or Close is called.
+func (w *Watcher) Start(ctx context.Context) error {
+	masters, err := w.discover(ctx)
+	if err != nil {
+		return err
+	}
+	w.publish(masters)
+
+	ctx, cancel := context.WithCancel(ctx)
+	w.cancel = cancel
+	w.done = make(chan struct{})
+
+	go w.watch(ctx)
+
+	return nil
+}
+
+// Close stops the background watch goroutine and waits for it to exit.
+func (w *Watcher) Close() error {
+	if w.cancel == nil {
+		return nil
+	}
+	w.cancel()
+	<-w.done
+	return nil
+}
+
+// NewRingPool is the wiring point between sentinel discovery and
+// pool.RingPool: it builds a RingPool, starts a Watcher that feeds it
+// SetMasters on every discovery and +switch-master/+odown update, and
+// returns both so the caller can route traffic through the pool and Close
+// them down together. Most callers that want a Sentinel-backed ring pool
+// should use this instead of wiring a Watcher and RingPool by hand.
+func NewRingPool(ctx context.Context, cfg Config, ringOpt *pool.RingOptions) (*pool.RingPool, *Watcher, error) {
+	ring := pool.NewRingPool(ringOpt)
+
+	w := NewWatcher(cfg, ring.SetMasters)
+	if err := w.Start(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	return ring, w, nil
+}
+
+func (w *Watcher) watch(ctx context.Context) {
+	defer close(w.done)
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err := w.listenOnce(ctx); err != nil {
+			internal.Logger.Printf(ctx, "sentinel: watch %v: %v", w.cfg.MasterNames, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
+
+// listenOnce subscribes to a single sentinel's __sentinel__:hello,
+// +switch-master and +odown channels and, on every message, re-resolves
+// the master set and refreshes the known sentinel list via
+// SENTINEL sentinels <name>.
+func (w *Watcher) listenOnce(ctx context.Context) error {
+	addr, err := w.pickSentinelAddr()
+	if err != nil {
+		return err
+	}
+
+	conn, rd, wr, err := w.dialSentinel(ctx, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Close the subscription conn once ctx is done so the blocking
+	// ReadReply below returns and Close does not wait on a quiet sentinel.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-stop:
+		}
+	}()
+
+	if err := wr.WriteArgs([]interface{}{"subscribe", "__sentinel__:hello", "+switch-master", "+odown"}); err != nil {
+		return err
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := rd.ReadReply(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		if _, err := rd.ReadReply(); err != nil {
+			return err
+		}
+
+		masters, err := w.discover(ctx)
+		if err != nil {
+			internal.Logger.Printf(ctx, "sentinel: refresh masters after notification: %v", err)
+			continue
+		}
+		w.refreshSentinelAddrs(ctx, addr)
+		w.publish(masters)
+	}
+}
+
+// discover resolves the current address of every configured master name
+// via SENTINEL masters.
+func (w *Watcher) discover(ctx context.Context) ([]MasterInfo, error) {
+	addr, err := w.pickSentinelAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, rd, wr, err := w.dialSentinel(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := wr.WriteArgs([]interface{}{"sentinel", "masters"}); err != nil {
+		return nil, err
+	}
+	reply, err := rd.ReadReply()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, _ := reply.([]interface{})
+	wanted := make(map[string]bool, len(w.cfg.MasterNames))
+	for _, name := range w.cfg.MasterNames {
+		wanted[name] = true
+	}
+
+	masters := make([]MasterInfo, 0, len(w.cfg.MasterNames))
+	for _, e := range entries {
+		fields, _ := e.([]interface{})
+		m := fieldsToMap(fields)
+		if !wanted[m["name"]] {
+			continue
+		}
+		masters = append(masters, MasterInfo{
+			Name: m["name"],
+			Addr: net.JoinHostPort(m["ip"], m["port"]),
+		})
+	}
+	return masters, nil
+}
+
+// refreshSentinelAddrs asks a known sentinel for its view of the other
+// sentinels watching each of Config.MasterNames, so the watcher keeps working across a
+// sentinel restart or reshuffle.
+func (w *Watcher) refreshSentinelAddrs(ctx context.Context, knownAddr string) {
+	conn, rd, wr, err := w.dialSentinel(ctx, knownAddr)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	addrs := map[string]bool{knownAddr: true}
+	for _, name := range w.cfg.MasterNames {
+		if err := wr.WriteArgs([]interface{}{"sentinel", "sentinels", name}); err != nil {
+			return
+		}
+		reply, err := rd.ReadReply()
+		if err != nil {
+			return
+		}
+		entries, _ := reply.([]interface{})
+		for _, e := range entries {
+			fields, _ := e.([]interface{})
+			m := fieldsToMap(fields)
+			if m["ip"] != "" && m["port"] != "" {
+				addrs[net.JoinHostPort(m["ip"], m["port"])] = true
+			}
+		}
+	}
+
+	w.mu.Lock()
+	w.sentinelAddrs = w.sentinelAddrs[:0]
+	for addr := range addrs {
+		w.sentinelAddrs = append(w.sentinelAddrs, addr)
+	}
+	w.mu.Unlock()
+}
+
+func (w *Watcher) publish(masters []MasterInfo) {
+	ringMasters := make([]pool.RingMaster, len(masters))
+	for i, m := range masters {
+		opt := *w.cfg.Options
+		addr := m.Addr
+		opt.Dialer = func(ctx context.Context) (net.Conn, error) {
+			d := net.Dialer{Timeout: w.cfg.DialTimeout}
+			return d.DialContext(ctx, "tcp", addr)
+		}
+		ringMasters[i] = pool.RingMaster{Name: m.Name, Addr: m.Addr, Opt: &opt}
+	}
+	w.onUpdate(ringMasters)
+}
+
+func (w *Watcher) pickSentinelAddr() (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.sentinelAddrs) == 0 {
+		return "", fmt.Errorf("redis: sentinel: no sentinel addresses configured")
+	}
+	return w.sentinelAddrs[0], nil
+}
+
+func (w *Watcher) dialSentinel(ctx context.Context, addr string) (net.Conn, *proto.Reader, *proto.Writer, error) {
+	d := net.Dialer{Timeout: w.cfg.DialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	rd := proto.NewReader(conn)
+	wr := proto.NewWriter(conn)
+
+	if w.cfg.SentinelPassword != "" {
+		if err := wr.WriteArgs([]interface{}{"auth", w.cfg.SentinelPassword}); err != nil {
+			conn.Close()
+			return nil, nil, nil, err
+		}
+		if _, err := rd.ReadReply(); err != nil {
+			conn.Close()
+			return nil, nil, nil, err
+		}
+	}
+
+	return conn, rd, wr, nil
+}
+
+// fieldsToMap turns a SENTINEL masters/sentinels flat field/value reply
+// into a lookup of the handful of fields the watcher cares about.
+func fieldsToMap(fields []interface{}) map[string]string {
+	m := make(map[string]string, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		k, _ := fields[i].(string)
+		v, _ := fields[i+1].(string)
+		m[k] = v
+	}
+	return m
+}
@@ -0,0 +1,208 @@
+package sentinel
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeevic/go-redis/v9/internal/pool"
+)
+
+func TestFieldsToMap(t *testing.T) {
+	fields := []interface{}{"name", "mymaster", "ip", "127.0.0.1", "port", "6379"}
+	got := fieldsToMap(fields)
+	want := map[string]string{"name": "mymaster", "ip": "127.0.0.1", "port": "6379"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("fieldsToMap(%v) = %v, want %v", fields, got, want)
+	}
+}
+
+func TestFieldsToMap_IgnoresDanglingField(t *testing.T) {
+	fields := []interface{}{"name", "mymaster", "dangling"}
+	got := fieldsToMap(fields)
+	want := map[string]string{"name": "mymaster"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("fieldsToMap(%v) = %v, want %v", fields, got, want)
+	}
+}
+
+// fakeSentinelServer answers every accepted connection's one command with
+// a canned SENTINEL masters reply built from masters, mirroring how
+// discover/dialSentinel use one short-lived connection per call.
+func fakeSentinelServer(t *testing.T, masters []MasterInfo) (addr string, closeFn func()) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeSentinel(conn, masters)
+		}
+	}()
+
+	return ln.Addr().String(), func() { _ = ln.Close() }
+}
+
+func serveFakeSentinel(conn net.Conn, masters []MasterInfo) {
+	defer conn.Close()
+
+	if _, err := readRESPCommand(bufio.NewReader(conn)); err != nil {
+		return
+	}
+
+	entries := make([]interface{}, len(masters))
+	for i, m := range masters {
+		ip, port, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return
+		}
+		entries[i] = []interface{}{"name", m.Name, "ip", ip, "port", port}
+	}
+	writeRESPValue(conn, entries)
+}
+
+// readRESPCommand reads one RESP array-of-bulk-strings command, draining
+// exactly what the client sent so closing the connection afterwards
+// doesn't race a still-in-flight reply with a reset.
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) == 0 || line[0] != '*' {
+		return nil, fmt.Errorf("sentinel_test: unexpected line %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := 0; i < n; i++ {
+		bulkLine, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		bulkLine = strings.TrimRight(bulkLine, "\r\n")
+		blen, err := strconv.Atoi(bulkLine[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, blen+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:blen])
+	}
+	return args, nil
+}
+
+// writeRESPValue encodes a string as a RESP bulk string and a
+// []interface{} as a RESP array, recursing into nested []interface{}
+// elements - enough to write a SENTINEL masters reply.
+func writeRESPValue(w io.Writer, v interface{}) {
+	switch vv := v.(type) {
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(vv), vv)
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(vv))
+		for _, item := range vv {
+			writeRESPValue(w, item)
+		}
+	}
+}
+
+func TestDiscover_ParsesWantedMasters(t *testing.T) {
+	addr, closeFn := fakeSentinelServer(t, []MasterInfo{
+		{Name: "mymaster", Addr: "10.0.0.1:6379"},
+		{Name: "other", Addr: "10.0.0.2:6379"},
+	})
+	defer closeFn()
+
+	w := NewWatcher(Config{
+		MasterNames:   []string{"mymaster"},
+		SentinelAddrs: []string{addr},
+		DialTimeout:   time.Second,
+	}, func([]pool.RingMaster) {})
+
+	masters, err := w.discover(context.Background())
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if len(masters) != 1 || masters[0].Name != "mymaster" || masters[0].Addr != "10.0.0.1:6379" {
+		t.Fatalf("discover() = %+v, want only mymaster at 10.0.0.1:6379", masters)
+	}
+}
+
+// TestPublish_ReflectsAddressChangeOnFailover is the scenario a
+// +switch-master represents: the master keeps its logical Name but its
+// Addr (and so the Dialer publish builds for it) moves to a new host:port.
+// Downstream (RingPool.SetMasters) relies on publish actually surfacing
+// that change rather than treating the known Name as unchanged.
+func TestPublish_ReflectsAddressChangeOnFailover(t *testing.T) {
+	oldLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen (old): %v", err)
+	}
+	defer oldLn.Close()
+
+	newLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen (new): %v", err)
+	}
+	defer newLn.Close()
+
+	var got []pool.RingMaster
+	w := NewWatcher(Config{
+		Options:     &pool.Options{PoolSize: 1},
+		DialTimeout: time.Second,
+	}, func(m []pool.RingMaster) { got = m })
+
+	w.publish([]MasterInfo{{Name: "mymaster", Addr: oldLn.Addr().String()}})
+	if len(got) != 1 || got[0].Addr != oldLn.Addr().String() {
+		t.Fatalf("publish before failover = %+v, want addr %s", got, oldLn.Addr())
+	}
+
+	// Same Name, new Addr: the +switch-master case.
+	w.publish([]MasterInfo{{Name: "mymaster", Addr: newLn.Addr().String()}})
+	if len(got) != 1 || got[0].Addr != newLn.Addr().String() {
+		t.Fatalf("publish after failover = %+v, want addr %s", got, newLn.Addr())
+	}
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, _ := newLn.Accept()
+		accepted <- c
+	}()
+
+	conn, err := got[0].Opt.Dialer(context.Background())
+	if err != nil {
+		t.Fatalf("Dialer after failover: %v", err)
+	}
+	defer conn.Close()
+
+	select {
+	case c := <-accepted:
+		if c == nil {
+			t.Fatalf("new address listener never accepted a connection from the post-failover Dialer")
+		}
+		c.Close()
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for the post-failover Dialer to connect to the new address")
+	}
+}